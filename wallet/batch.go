@@ -0,0 +1,163 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// defaultMaxConcurrency PayBatch 默认并发 worker 数
+const defaultMaxConcurrency = 4
+
+// defaultApproveFeeChunkSize ApproveAdvanceFeeChunked 单次请求携带的最大 bill ID 数
+const defaultApproveFeeChunkSize = 100
+
+// PayRequest PayBatch 的单笔支付请求
+type PayRequest struct {
+	Address   string
+	ToAddress string
+	Amount    float64
+	Fee       float64
+}
+
+// PayBatchResult PayBatch 中单笔请求的处理结果，Err 非 nil 时 Response 可能为 nil
+type PayBatchResult struct {
+	Request  PayRequest
+	Response *PayResponse
+	Err      error
+}
+
+// PayBatch 并发执行一批支付请求，worker 数由 Config.MaxConcurrency 控制，
+// 结果按完成顺序通过返回的 channel 流式产出；channel 在所有请求处理完毕后关闭。
+// 单笔请求失败不会影响其余请求，调用方需要逐个检查 PayBatchResult.Err。
+func (w *Client) PayBatch(ctx context.Context, reqs []PayRequest) (<-chan PayBatchResult, error) {
+	if len(reqs) == 0 {
+		return nil, fmt.Errorf("wallet: PayBatch requires at least one request")
+	}
+
+	jobs := make(chan PayRequest)
+	out := make(chan PayBatchResult, len(reqs))
+	limiter := newTokenBucket(w.rateLimit())
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.maxConcurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for req := range jobs {
+				if err := limiter.Wait(ctx); err != nil {
+					out <- PayBatchResult{Request: req, Err: err}
+					continue
+				}
+				resp, err := w.PayContext(ctx, req.Address, req.ToAddress, req.Amount, req.Fee)
+				out <- PayBatchResult{Request: req, Response: resp, Err: err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for _, req := range reqs {
+			select {
+			case jobs <- req:
+			case <-ctx.Done():
+				out <- PayBatchResult{Request: req, Err: ctx.Err()}
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out, nil
+}
+
+// maxConcurrency 返回 Config.MaxConcurrency，未设置时回退到 defaultMaxConcurrency
+func (w *Client) maxConcurrency() int {
+	if w.conf != nil && w.conf.MaxConcurrency > 0 {
+		return w.conf.MaxConcurrency
+	}
+	return defaultMaxConcurrency
+}
+
+// rateLimit 返回 Config.RateLimit（qps），未设置（<=0）表示不限速
+func (w *Client) rateLimit() float64 {
+	if w.conf == nil {
+		return 0
+	}
+	return w.conf.RateLimit
+}
+
+// approveFeeChunkSize 返回 Config.ApproveFeeChunkSize，未设置时回退到 defaultApproveFeeChunkSize
+func (w *Client) approveFeeChunkSize() int {
+	if w.conf != nil && w.conf.ApproveFeeChunkSize > 0 {
+		return w.conf.ApproveFeeChunkSize
+	}
+	return defaultApproveFeeChunkSize
+}
+
+// ApproveAdvanceFeeChunked 批准预付小费，billIDs 数量超过 approveFeeChunkSize 时
+// 会被透明地拆分为多次 /advance/approve_fee 请求，并对 fee_sum/affected 求和。
+// 某个分片失败时立即返回，已成功的分片结果会保留在返回值中（该批准操作不可回滚）。
+func (w *Client) ApproveAdvanceFeeChunked(ctx context.Context, billIDs []string) (*ApproveAdvanceFeeResult, error) {
+	size := w.approveFeeChunkSize()
+	result := &ApproveAdvanceFeeResult{}
+	for i := 0; i < len(billIDs); i += size {
+		end := int(math.Min(float64(i+size), float64(len(billIDs))))
+		resp, err := w.ApproveAdvanceFeeContext(ctx, billIDs[i:end])
+		if err != nil {
+			return result, fmt.Errorf("wallet: approve_fee chunk [%d:%d): %w", i, end, err)
+		}
+		result.FeeSum += resp.Result.FeeSum
+		result.Affected += resp.Result.Affected
+	}
+	return result, nil
+}
+
+// tokenBucket 是一个简单的令牌桶限流器，填充速率为 qps，容量为 max(qps, 1)
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	max    float64
+	rate   float64
+	last   time.Time
+}
+
+// newTokenBucket 创建限流器，qps<=0 表示不限速（返回 nil，Wait 直接放行）
+func newTokenBucket(qps float64) *tokenBucket {
+	if qps <= 0 {
+		return nil
+	}
+	capacity := math.Max(qps, 1)
+	return &tokenBucket{tokens: capacity, max: capacity, rate: qps, last: time.Now()}
+}
+
+// Wait 阻塞直到获得一个令牌或 ctx 结束
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens = math.Min(b.max, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPayBatchProcessesAllRequestsConcurrently(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(`{"balance":1,"bill_id":"b"}`))
+	}))
+	defer ts.Close()
+
+	w := &Client{conf: &Config{Host: ts.URL, MaxConcurrency: 2}}
+	reqs := []PayRequest{
+		{Address: "a1", ToAddress: "b1", Amount: 1, Fee: 0.1},
+		{Address: "a2", ToAddress: "b2", Amount: 2, Fee: 0.1},
+		{Address: "a3", ToAddress: "b3", Amount: 3, Fee: 0.1},
+	}
+
+	out, err := w.PayBatch(context.Background(), reqs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := make(map[string]PayBatchResult)
+	for res := range out {
+		got[res.Request.Address] = res
+	}
+	if len(got) != len(reqs) {
+		t.Fatalf("expected %d results, got %d", len(reqs), len(got))
+	}
+	for _, req := range reqs {
+		res, ok := got[req.Address]
+		if !ok {
+			t.Fatalf("missing result for %s", req.Address)
+		}
+		if res.Err != nil {
+			t.Fatalf("unexpected per-request error for %s: %v", req.Address, res.Err)
+		}
+	}
+	if got := atomic.LoadInt32(&calls); got != int32(len(reqs)) {
+		t.Fatalf("expected %d upstream calls, got %d", len(reqs), got)
+	}
+}
+
+func TestPayBatchRejectsEmptyInput(t *testing.T) {
+	w := &Client{conf: &Config{}}
+	if _, err := w.PayBatch(context.Background(), nil); err == nil {
+		t.Fatalf("expected error for an empty request list")
+	}
+}
+
+func TestPayBatchStopsQueuingOnContextCancellation(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	w := &Client{conf: &Config{Host: ts.URL, MaxConcurrency: 1}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	reqs := []PayRequest{{Address: "a1", ToAddress: "b1", Amount: 1, Fee: 0.1}}
+	out, err := w.PayBatch(ctx, reqs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res := <-out
+	if res.Err == nil {
+		t.Fatalf("expected a cancellation error for a request queued against a cancelled context")
+	}
+}
+
+func TestApproveAdvanceFeeChunkedSplitsAndSums(t *testing.T) {
+	var gotChunks [][]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Bills []string `json:"bills"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decode request body: %v", err)
+		}
+		gotChunks = append(gotChunks, body.Bills)
+		w.Write([]byte(`{"fee_sum":1.5,"affected":2}`))
+	}))
+	defer ts.Close()
+
+	w := &Client{conf: &Config{Host: ts.URL, ApproveFeeChunkSize: 2}}
+	billIDs := []string{"1", "2", "3", "4", "5"}
+
+	result, err := w.ApproveAdvanceFeeChunked(context.Background(), billIDs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(gotChunks) != 3 {
+		t.Fatalf("expected 3 chunks for 5 ids at chunk size 2, got %d", len(gotChunks))
+	}
+	if result.FeeSum != 4.5 {
+		t.Fatalf("expected fee_sum to be summed across chunks (4.5), got %v", result.FeeSum)
+	}
+	if result.Affected != 6 {
+		t.Fatalf("expected affected to be summed across chunks (6), got %v", result.Affected)
+	}
+}
+
+func TestApproveAdvanceFeeChunkedStopsAtFirstFailure(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Write([]byte(`{"fee_sum":1,"affected":1}`))
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	w := &Client{conf: &Config{
+		Host:                ts.URL,
+		ApproveFeeChunkSize: 1,
+		RetryPolicy:         RetryPolicy{MaxAttempts: 1},
+	}}
+	result, err := w.ApproveAdvanceFeeChunked(context.Background(), []string{"1", "2"})
+	if err == nil {
+		t.Fatalf("expected an error once a chunk fails")
+	}
+	if result.Affected != 1 {
+		t.Fatalf("expected the first, successful chunk's result to be preserved, got affected=%d", result.Affected)
+	}
+}
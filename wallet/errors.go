@@ -0,0 +1,44 @@
+package wallet
+
+import "fmt"
+
+// APIError 钱包后端返回的非预期业务状态码
+type APIError struct {
+	StatusCode int
+	Body       map[string]interface{}
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("wallet: unexpected status %d: %v", e.StatusCode, e.Body)
+}
+
+// InsufficientBalanceError 对应文档中约定的 HTTP 600（余额不足），携带返回的余额
+type InsufficientBalanceError struct {
+	Balance float64
+}
+
+func (e *InsufficientBalanceError) Error() string {
+	return fmt.Sprintf("wallet: insufficient balance, balance=%f", e.Balance)
+}
+
+// Is 使 errors.Is(err, wallet.ErrInsufficientBalance) 只按类型匹配，忽略 Balance 具体值
+func (e *InsufficientBalanceError) Is(target error) bool {
+	_, ok := target.(*InsufficientBalanceError)
+	return ok
+}
+
+// ErrInsufficientBalance 用于 errors.Is 判断余额不足（HTTP 600）
+var ErrInsufficientBalance error = &InsufficientBalanceError{}
+
+// newStatusError 依据 HTTP 状态码和解析后的响应体构造对应的业务错误，200 返回 nil
+func newStatusError(status int, data map[string]interface{}) error {
+	switch status {
+	case 200:
+		return nil
+	case 600:
+		balance, _ := data["balance"].(float64)
+		return &InsufficientBalanceError{Balance: balance}
+	default:
+		return &APIError{StatusCode: status, Body: data}
+	}
+}
@@ -0,0 +1,102 @@
+package wallet
+
+import (
+	"context"
+	"time"
+
+	"github.com/panjiang/golog"
+	"gorm.io/gorm"
+)
+
+// RequestLog 一次请求的审计记录
+type RequestLog struct {
+	Method       string
+	URL          string
+	RequestBody  string
+	StatusCode   int
+	ResponseBody string
+	Duration     time.Duration
+	Err          error
+}
+
+// RequestLogger 请求审计接口，Client 默认使用 noopLogger，不落地任何记录
+type RequestLogger interface {
+	LogRequest(ctx context.Context, entry *RequestLog)
+}
+
+// noopLogger 默认空实现
+type noopLogger struct{}
+
+func (noopLogger) LogRequest(ctx context.Context, entry *RequestLog) {}
+
+// SetLogger 设置请求审计实现，nil 表示恢复为默认的空实现
+func (w *Client) SetLogger(l RequestLogger) {
+	if l == nil {
+		l = noopLogger{}
+	}
+	w.logger = l
+}
+
+func (w *Client) requestLogger() RequestLogger {
+	if w.logger == nil {
+		return noopLogger{}
+	}
+	return w.logger
+}
+
+// EnableGormLogging 是 SetLogger(NewGormLogger(db, Config.LogTable)) 的便捷写法，
+// 使操作方只需配置 Config.LogTable 即可开启审计，无需自行拼装 GormLogger
+func (w *Client) EnableGormLogging(db *gorm.DB) {
+	table := ""
+	if w.conf != nil {
+		table = w.conf.LogTable
+	}
+	w.SetLogger(NewGormLogger(db, table))
+}
+
+// WalletRequestLog 钱包请求审计表默认结构，配合 NewGormLogger 使用
+// 表名由 NewGormLogger 的 table 参数决定，而非该结构体名
+type WalletRequestLog struct {
+	ID           uint      `gorm:"primarykey"`
+	Method       string    `gorm:"column:method;size:8"`
+	URL          string    `gorm:"column:url"`
+	RequestBody  string    `gorm:"column:request_body"`
+	StatusCode   int       `gorm:"column:status_code"`
+	ResponseBody string    `gorm:"column:response_body"`
+	DurationMS   int64     `gorm:"column:duration_ms"`
+	Error        string    `gorm:"column:error"`
+	CreatedAt    time.Time `gorm:"column:created_at"`
+}
+
+// GormLogger 基于 GORM 的请求审计落地实现，将每次请求写入 pgsql（或其他 GORM 支持的数据库）
+type GormLogger struct {
+	db    *gorm.DB
+	table string
+}
+
+// NewGormLogger 创建一个写入 table 表的 GORM 审计实现，table 为空时使用默认表名 wallet_request_logs
+func NewGormLogger(db *gorm.DB, table string) *GormLogger {
+	if table == "" {
+		table = "wallet_request_logs"
+	}
+	return &GormLogger{db: db, table: table}
+}
+
+// LogRequest 实现 RequestLogger，写入失败仅记录日志，不影响主调用链路
+func (g *GormLogger) LogRequest(ctx context.Context, entry *RequestLog) {
+	record := WalletRequestLog{
+		Method:       entry.Method,
+		URL:          entry.URL,
+		RequestBody:  entry.RequestBody,
+		StatusCode:   entry.StatusCode,
+		ResponseBody: entry.ResponseBody,
+		DurationMS:   entry.Duration.Milliseconds(),
+		CreatedAt:    time.Now(),
+	}
+	if entry.Err != nil {
+		record.Error = entry.Err.Error()
+	}
+	if err := g.db.WithContext(ctx).Table(g.table).Create(&record).Error; err != nil {
+		log.Debugf("wallet: failed to persist request log: %v", err)
+	}
+}
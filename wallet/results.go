@@ -0,0 +1,115 @@
+package wallet
+
+import "net/http"
+
+// CreateAddressResult 创建钱包地址的业务返回
+type CreateAddressResult struct {
+	Account string `json:"account"`
+	Address string `json:"address"`
+	New     bool   `json:"new"`
+}
+
+// CreateAddressResponse CreateAddressContext 的完整返回，包含业务结果、原始响应体和 http 元数据
+type CreateAddressResponse struct {
+	Result CreateAddressResult
+	Body   []byte
+	Http   *http.Response
+	Err    error
+}
+
+// BalanceResult 获取余额的业务返回
+type BalanceResult struct {
+	Balance float64 `json:"balance"`
+}
+
+// BalanceResponse GetBalanceContext 的完整返回
+type BalanceResponse struct {
+	Result BalanceResult
+	Body   []byte
+	Http   *http.Response
+	Err    error
+}
+
+// SyncBalanceResult 同步余额的业务返回
+type SyncBalanceResult struct {
+	Balance  float64 `json:"balance"`
+	Recharge float64 `json:"recharge"`
+}
+
+// SyncBalanceResponse SyncBalanceContext 的完整返回
+type SyncBalanceResponse struct {
+	Result SyncBalanceResult
+	Body   []byte
+	Http   *http.Response
+	Err    error
+}
+
+// PayResult 支付的业务返回
+type PayResult struct {
+	Balance float64 `json:"balance"`
+	BillID  string  `json:"bill_id"`
+}
+
+// PayResponse PayContext 的完整返回
+type PayResponse struct {
+	Result PayResult
+	Body   []byte
+	Http   *http.Response
+	Err    error
+}
+
+// FeeResult 支付小费的业务返回
+type FeeResult struct {
+	Balance float64 `json:"balance"`
+	BillID  string  `json:"bill_id"`
+}
+
+// FeeResponse FeeContext 的完整返回
+type FeeResponse struct {
+	Result FeeResult
+	Body   []byte
+	Http   *http.Response
+	Err    error
+}
+
+// AdvanceFeeResult 预付小费的业务返回
+type AdvanceFeeResult struct {
+	Balance float64 `json:"balance"`
+	BillID  string  `json:"bill_id"`
+}
+
+// AdvanceFeeResponse AdvanceFeeContext 的完整返回
+type AdvanceFeeResponse struct {
+	Result AdvanceFeeResult
+	Body   []byte
+	Http   *http.Response
+	Err    error
+}
+
+// ApproveAdvanceFeeResult 批准预付小费（批量）的业务返回
+type ApproveAdvanceFeeResult struct {
+	FeeSum   float64 `json:"fee_sum"`
+	Affected int     `json:"affected"`
+}
+
+// ApproveAdvanceFeeResponse ApproveAdvanceFeeContext 的完整返回
+type ApproveAdvanceFeeResponse struct {
+	Result ApproveAdvanceFeeResult
+	Body   []byte
+	Http   *http.Response
+	Err    error
+}
+
+// CancelAdvanceFeeResult 取消预付小费（单个）的业务返回
+type CancelAdvanceFeeResult struct {
+	Fee      float64 `json:"fee"`
+	Affected int     `json:"affected"`
+}
+
+// CancelAdvanceFeeResponse CancelAdvanceFeeContext 的完整返回
+type CancelAdvanceFeeResponse struct {
+	Result CancelAdvanceFeeResult
+	Body   []byte
+	Http   *http.Response
+	Err    error
+}
@@ -0,0 +1,115 @@
+package wallet
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy 控制幂等请求的自动重试行为
+type RetryPolicy struct {
+	MaxAttempts    int           // 含首次请求在内的最大尝试次数
+	InitialBackoff time.Duration // 首次重试前的等待时间
+	MaxBackoff     time.Duration // 单次等待的上限
+	Jitter         bool          // 是否在等待时间上叠加随机抖动，避免重试风暴
+}
+
+// defaultRetryPolicy 默认重试策略：最多尝试 3 次，初始退避 200ms，上限 2s，开启抖动
+var defaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+	Jitter:         true,
+}
+
+// retryPolicy 返回 Config.RetryPolicy，未设置时回退到 defaultRetryPolicy
+func (w *Client) retryPolicy() RetryPolicy {
+	if w.conf != nil && w.conf.RetryPolicy.MaxAttempts > 0 {
+		return w.conf.RetryPolicy
+	}
+	return defaultRetryPolicy
+}
+
+// backoff 计算第 attempt 次重试（从 1 开始）前的等待时间
+func backoff(policy RetryPolicy, attempt int) time.Duration {
+	d := float64(policy.InitialBackoff) * math.Pow(2, float64(attempt-1))
+	if max := float64(policy.MaxBackoff); max > 0 && d > max {
+		d = max
+	}
+	wait := time.Duration(d)
+	if policy.Jitter && wait > 0 {
+		if n, err := rand.Int(rand.Reader, big.NewInt(int64(wait))); err == nil {
+			wait = time.Duration(n.Int64())
+		}
+	}
+	return wait
+}
+
+// retryAfter 解析 Retry-After 响应头（秒数形式），未设置或无法解析时返回 0
+func retryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// shouldRetryStatus 是否应当基于状态码重试：仅 5xx（600 余额不足等业务码不在此范围内，不重试）
+func shouldRetryStatus(status int) bool {
+	return status >= 500 && status < 600
+}
+
+// shouldRetryError 是否应当基于传输错误重试：仅连接类错误重试，
+// ctx 已被取消或已超时的请求不重试（重试也无法在到期的 ctx 下成功）
+func shouldRetryError(ctx context.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || ctx.Err() == context.DeadlineExceeded {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// isIdempotent 幂等的请求才允许自动重试：GET 天然幂等，POST 需要携带 Idempotency-Key
+func isIdempotent(method string, idemKey string) bool {
+	return method == MethodGet || idemKey != ""
+}
+
+// newIdempotencyKey 生成形如 "pay:from:to:amount:fee:nonce" 的稳定幂等键，
+// nonce 在一次逻辑调用内生成一次，随重试原样复用，避免同一笔操作被重复执行
+func newIdempotencyKey(prefix string, parts ...interface{}) string {
+	segs := make([]string, 0, len(parts)+2)
+	segs = append(segs, prefix)
+	for _, p := range parts {
+		segs = append(segs, fmt.Sprintf("%v", p))
+	}
+	segs = append(segs, nonce())
+	return strings.Join(segs, ":")
+}
+
+// nonce 生成一个随机的十六进制串，用于幂等键的唯一化
+func nonce() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(int64(time.Now().Nanosecond()), 16)
+	}
+	return hex.EncodeToString(b)
+}
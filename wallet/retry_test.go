@@ -0,0 +1,182 @@
+package wallet
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testRetryClient 返回一个指向 ts 的 Client，重试退避被压到毫秒级以保持测试快速
+func testRetryClient(ts *httptest.Server) *Client {
+	return &Client{conf: &Config{
+		Host:     ts.URL,
+		Username: "u",
+		Password: "p",
+		RetryPolicy: RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     5 * time.Millisecond,
+		},
+	}}
+}
+
+func TestDoRequestRetriesOn5xxThenSucceeds(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{}`))
+			return
+		}
+		w.Write([]byte(`{"balance":1}`))
+	}))
+	defer ts.Close()
+
+	w := testRetryClient(ts)
+	status, _, _, _, err := w.doRequest(context.Background(), MethodPost, ts.URL, nil, "idem-key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status != http.StatusOK {
+		t.Fatalf("expected final status 200, got %d", status)
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestDoRequestDoesNotRetryBusinessStatus(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(600)
+		w.Write([]byte(`{"balance":0}`))
+	}))
+	defer ts.Close()
+
+	w := testRetryClient(ts)
+	status, _, _, _, err := w.doRequest(context.Background(), MethodPost, ts.URL, nil, "idem-key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status != 600 {
+		t.Fatalf("expected status 600, got %d", status)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("business status 600 must not be retried, got %d calls", got)
+	}
+}
+
+func TestDoRequestHonorsRetryAfterHeader(t *testing.T) {
+	var calls int32
+	var firstAt, secondAt time.Time
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			firstAt = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{}`))
+		default:
+			secondAt = time.Now()
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer ts.Close()
+
+	w := testRetryClient(ts)
+	_, _, _, _, err := w.doRequest(context.Background(), MethodPost, ts.URL, nil, "idem-key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wait := secondAt.Sub(firstAt); wait < 900*time.Millisecond {
+		t.Fatalf("expected retry to honor Retry-After: 1s, only waited %v", wait)
+	}
+}
+
+func TestDoRequestStopsOnCancelledContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	w := testRetryClient(ts)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, _, _, err := w.doRequest(ctx, MethodPost, ts.URL, nil, "idem-key")
+	if err == nil {
+		t.Fatalf("expected error from a request made with an already-cancelled context")
+	}
+}
+
+func TestIdempotencyKeyStableAcrossRetries(t *testing.T) {
+	var keys []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if len(keys) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	w := testRetryClient(ts)
+	idemKey := newIdempotencyKey("pay", "a", "b", 1.0, 0.1)
+	_, _, _, _, err := w.doRequest(context.Background(), MethodPost, ts.URL, nil, idemKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(keys))
+	}
+	for i, k := range keys {
+		if k != idemKey {
+			t.Fatalf("attempt %d sent idempotency key %q, want stable key %q across retries", i, k, idemKey)
+		}
+	}
+}
+
+func TestShouldRetryStatus(t *testing.T) {
+	cases := []struct {
+		status int
+		want   bool
+	}{
+		{200, false},
+		{499, false},
+		{500, true},
+		{599, true},
+		{600, false},
+	}
+	for _, c := range cases {
+		if got := shouldRetryStatus(c.status); got != c.want {
+			t.Errorf("shouldRetryStatus(%d) = %v, want %v", c.status, got, c.want)
+		}
+	}
+}
+
+func TestRetryAfterParsesSeconds(t *testing.T) {
+	cases := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"-1", 0},
+		{"not-a-number", 0},
+	}
+	for _, c := range cases {
+		resp := &http.Response{Header: http.Header{}}
+		if c.header != "" {
+			resp.Header.Set("Retry-After", c.header)
+		}
+		if got := retryAfter(resp); got != c.want {
+			t.Errorf("retryAfter(%q) = %v, want %v", c.header, got, c.want)
+		}
+	}
+}
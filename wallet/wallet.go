@@ -2,12 +2,16 @@ package wallet
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"path"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/panjiang/golog"
 )
@@ -18,6 +22,9 @@ const (
 	MethodPost = "POST"
 )
 
+// defaultTimeout 默认请求超时时间
+const defaultTimeout = 10 * time.Second
+
 // API global instance
 var API Client
 
@@ -32,49 +39,188 @@ type Config struct {
 	Product  string `json:"product"`
 	Username string `json:"username"`
 	Password string `json:"password"`
+
+	// Timeout 单次请求超时时间，不设置则使用 defaultTimeout
+	Timeout time.Duration `json:"timeout"`
+
+	// HTTPClient 允许调用方自定义带连接池的 http.Client，不设置则使用内置默认值
+	HTTPClient *http.Client `json:"-"`
+
+	// LogTable 请求审计表名，配合 Client.EnableGormLogging 使用；
+	// 直接调用 NewGormLogger 时该字段不生效，table 以调用参数为准
+	LogTable string `json:"log_table"`
+
+	// RetryPolicy 幂等请求的自动重试策略，零值回退到 defaultRetryPolicy
+	RetryPolicy RetryPolicy `json:"retry_policy"`
+
+	// WebhookSecret 校验 NewWebhookHandler 收到的回调签名使用的共享密钥
+	WebhookSecret string `json:"webhook_secret"`
+
+	// WebhookDedupTTL NewWebhookHandler 识别重复投递的去重窗口，
+	// 与签名时间戳的 replayWindow 无关，不设置则回退到 defaultDedupTTL
+	WebhookDedupTTL time.Duration `json:"webhook_dedup_ttl"`
+
+	// MaxConcurrency PayBatch 的 worker 并发数，未设置时回退到 defaultMaxConcurrency
+	MaxConcurrency int `json:"max_concurrency"`
+
+	// RateLimit PayBatch 的限流速率（qps），未设置或 <=0 表示不限速
+	RateLimit float64 `json:"rate_limit"`
+
+	// ApproveFeeChunkSize ApproveAdvanceFeeChunked 单次请求携带的最大 bill ID 数，
+	// 未设置时回退到 defaultApproveFeeChunkSize
+	ApproveFeeChunkSize int `json:"approve_fee_chunk_size"`
 }
 
 // Client 钱包
 type Client struct {
-	conf *Config
+	conf       *Config
+	httpOnce   sync.Once
+	httpClient *http.Client
+	logger     RequestLogger
+}
+
+// httpCli 返回带连接池配置的 http.Client，优先使用 Config.HTTPClient。
+// 内置默认客户端仅构造一次（sync.Once），避免并发调用（如 PayBatch）下的数据竞争。
+func (w *Client) httpCli() *http.Client {
+	if w.conf != nil && w.conf.HTTPClient != nil {
+		return w.conf.HTTPClient
+	}
+	w.httpOnce.Do(func() {
+		timeout := defaultTimeout
+		if w.conf != nil && w.conf.Timeout > 0 {
+			timeout = w.conf.Timeout
+		}
+		w.httpClient = &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				MaxIdleConns:        100,
+				MaxIdleConnsPerHost: 20,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		}
+	})
+	return w.httpClient
 }
 
 // Request 请求Exchange-API
+//
+// Deprecated: 请使用 RequestContext，该方法会在下个大版本移除。
 func (w *Client) Request(method string, url string, v interface{}) (int, map[string]interface{}) {
-	var data map[string]interface{}
+	return w.RequestContext(context.Background(), method, url, v)
+}
+
+// RequestContext 请求Exchange-API，支持 ctx 超时/取消控制
+//
+// 注意：该方法签名无法携带 error，传输层错误（连接失败、ctx 取消等，
+// 即没有拿到任何 HTTP 响应）会直接 panic；
+// 拿到响应后的读取/解码类错误则返回该响应的状态码和 nil data。需要感知底层错误的调用方请改用
+// 各 *Context 方法或 doRequest。
+func (w *Client) RequestContext(ctx context.Context, method string, url string, v interface{}) (int, map[string]interface{}) {
+	status, data, _, _, err := w.doRequest(ctx, method, url, v, "")
+	if err != nil && status == 0 {
+		log.Panic("wallet:", method, url, err)
+	}
+	return status, data
+}
 
-	reqBody := new(bytes.Buffer)
+// doRequest 发起请求并返回状态码、解析后的业务数据、原始响应体、http.Response 和错误
+// （传输层错误，或响应体读取/JSON 解码错误），
+// 供 RequestContext（兼容旧签名）和各类型化的 *Context 方法共用。
+// idemKey 非空时会通过 Idempotency-Key 请求头随请求一起发送，使 POST 请求可被安全重试；
+// GET 请求天然幂等，idemKey 可以为空。
+// 5xx、连接错误会按 Config.RetryPolicy 重试，业务错误（如余额不足的 600）不会重试。
+func (w *Client) doRequest(ctx context.Context, method string, url string, v interface{}, idemKey string) (int, map[string]interface{}, []byte, *http.Response, error) {
+	var reqBodyBytes []byte
 	if v != nil {
-		json.NewEncoder(reqBody).Encode(v)
+		b, err := json.Marshal(v)
+		if err != nil {
+			return 0, nil, nil, nil, err
+		}
+		reqBodyBytes = b
+	}
+	log.Debugf("reqBody: %s", reqBodyBytes)
+
+	policy := w.retryPolicy()
+	retryable := isIdempotent(method, idemKey)
+
+	var (
+		status int
+		data   map[string]interface{}
+		body   []byte
+		resp   *http.Response
+		err    error
+	)
+
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		start := time.Now()
+		entry := &RequestLog{Method: method, URL: url, RequestBody: string(reqBodyBytes)}
+
+		status, data, body, resp, err = w.doRequestOnce(ctx, method, url, reqBodyBytes, idemKey, entry)
+
+		entry.Duration = time.Since(start)
+		w.requestLogger().LogRequest(ctx, entry)
+
+		if err == nil && !shouldRetryStatus(status) {
+			return status, data, body, resp, nil
+		}
+		if err != nil && !shouldRetryStatus(status) && !shouldRetryError(ctx, err) {
+			return status, data, body, resp, err
+		}
+		if !retryable || attempt == policy.MaxAttempts {
+			return status, data, body, resp, err
+		}
+
+		wait := retryAfter(resp)
+		if wait == 0 {
+			wait = backoff(policy, attempt)
+		}
+		select {
+		case <-ctx.Done():
+			return status, data, body, resp, ctx.Err()
+		case <-time.After(wait):
+		}
 	}
-	log.Debugf("reqBody: %+v", reqBody)
 
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, reqBody)
+	return status, data, body, resp, err
+}
+
+// doRequestOnce 发起单次 HTTP 请求，不处理重试
+func (w *Client) doRequestOnce(ctx context.Context, method string, url string, reqBodyBytes []byte, idemKey string, entry *RequestLog) (int, map[string]interface{}, []byte, *http.Response, error) {
+	var data map[string]interface{}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(reqBodyBytes))
 	if err != nil {
-		log.Panic("http", method, err)
+		entry.Err = err
+		return 0, data, nil, nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.SetBasicAuth(w.conf.Username, w.conf.Password)
-	resp, err := client.Do(req)
+	if idemKey != "" {
+		req.Header.Set("Idempotency-Key", idemKey)
+	}
+	resp, err := w.httpCli().Do(req)
 	if err != nil {
-		log.Panic("http", method, err)
+		entry.Err = err
+		return 0, data, nil, nil, err
 	}
-
 	defer resp.Body.Close()
 
+	entry.StatusCode = resp.StatusCode
+
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return resp.StatusCode, data
+		entry.Err = err
+		return resp.StatusCode, data, body, resp, err
 	}
+	entry.ResponseBody = string(body)
 	log.Debugf("%s %s\n%d %s", method, url, resp.StatusCode, body)
 
-	err = json.Unmarshal(body, &data)
-	if err != nil {
-		return resp.StatusCode, data
+	if err := json.Unmarshal(body, &data); err != nil {
+		entry.Err = err
+		return resp.StatusCode, data, body, resp, err
 	}
 
-	return resp.StatusCode, data
+	return resp.StatusCode, data, body, resp, nil
 }
 
 func (w *Client) fullURL(uri string) string {
@@ -89,38 +235,152 @@ func (w *Client) fullURL(uri string) string {
 // CreateAddress 创建钱包地址
 // PATH: /v2/wallet/:product(\\w+)/:uid(\\d+)/address
 // return 200: { account(string), address(string), new(bool) }
+//
+// Deprecated: 请使用 CreateAddressContext，该方法会在下个大版本移除。
 func (w *Client) CreateAddress(uid uint) (int, map[string]interface{}) {
-	return w.Request(MethodGet, w.fullURL(fmt.Sprintf("/%s/%d/address", w.conf.Product, uid)), nil)
+	return w.RequestContext(context.Background(), MethodGet, w.fullURL(fmt.Sprintf("/%s/%d/address", w.conf.Product, uid)), nil)
+}
+
+// CreateAddressContext 创建钱包地址，支持 ctx 超时/取消控制
+// PATH: /v2/wallet/:product(\\w+)/:uid(\\d+)/address
+// return 200: { account(string), address(string), new(bool) }
+func (w *Client) CreateAddressContext(ctx context.Context, uid uint) (*CreateAddressResponse, error) {
+	status, data, body, resp, err := w.doRequest(ctx, MethodGet, w.fullURL(fmt.Sprintf("/%s/%d/address", w.conf.Product, uid)), nil, "")
+	res := &CreateAddressResponse{Body: body, Http: resp}
+	if err != nil {
+		res.Err = err
+		return res, err
+	}
+	if err := newStatusError(status, data); err != nil {
+		res.Err = err
+		return res, err
+	}
+	res.Result.Account, _ = data["account"].(string)
+	res.Result.Address, _ = data["address"].(string)
+	res.Result.New, _ = data["new"].(bool)
+	return res, nil
 }
 
 // GetBalance 获取余额
 // PATH: /:address(\\w{34})/balance
 // return 200: { balance(float) }
+//
+// Deprecated: 请使用 GetBalanceContext，该方法会在下个大版本移除。
 func (w *Client) GetBalance(address string) (int, map[string]interface{}) {
-	return w.Request(MethodGet, w.fullURL(fmt.Sprintf("/%s/balance", address)), nil)
+	return w.RequestContext(context.Background(), MethodGet, w.fullURL(fmt.Sprintf("/%s/balance", address)), nil)
+}
+
+// GetBalanceContext 获取余额，支持 ctx 超时/取消控制
+// PATH: /:address(\\w{34})/balance
+// return 200: { balance(float) }
+func (w *Client) GetBalanceContext(ctx context.Context, address string) (*BalanceResponse, error) {
+	status, data, body, resp, err := w.doRequest(ctx, MethodGet, w.fullURL(fmt.Sprintf("/%s/balance", address)), nil, "")
+	res := &BalanceResponse{Body: body, Http: resp}
+	if err != nil {
+		res.Err = err
+		return res, err
+	}
+	if err := newStatusError(status, data); err != nil {
+		res.Err = err
+		return res, err
+	}
+	res.Result.Balance, _ = data["balance"].(float64)
+	return res, nil
 }
 
 // SyncBalance 同步余额
 // PATH: /:address(\\w{34})/sync_balance
 // return 200: { balance(float), recharge(float) }
+//
+// Deprecated: 请使用 SyncBalanceContext，该方法会在下个大版本移除。
 func (w *Client) SyncBalance(address string) (int, map[string]interface{}) {
-	return w.Request(MethodPost, w.fullURL(fmt.Sprintf("/%s/sync_balance", address)), nil)
+	return w.RequestContext(context.Background(), MethodPost, w.fullURL(fmt.Sprintf("/%s/sync_balance", address)), nil)
+}
+
+// SyncBalanceContext 同步余额，支持 ctx 超时/取消控制
+// PATH: /:address(\\w{34})/sync_balance
+// return 200: { balance(float), recharge(float) }
+func (w *Client) SyncBalanceContext(ctx context.Context, address string) (*SyncBalanceResponse, error) {
+	idemKey := newIdempotencyKey("sync_balance", address)
+	status, data, body, resp, err := w.doRequest(ctx, MethodPost, w.fullURL(fmt.Sprintf("/%s/sync_balance", address)), nil, idemKey)
+	res := &SyncBalanceResponse{Body: body, Http: resp}
+	if err != nil {
+		res.Err = err
+		return res, err
+	}
+	if err := newStatusError(status, data); err != nil {
+		res.Err = err
+		return res, err
+	}
+	res.Result.Balance, _ = data["balance"].(float64)
+	res.Result.Recharge, _ = data["recharge"].(float64)
+	return res, nil
 }
 
 // Pay 支付
 // PATH: /:address(\\w{34})/pay/:to_address(\\w{34})/:amount/:fee
 // return 200: { balance(float), bill_id(string) }
 // return 600: { balance(float) } 余额不足
+//
+// example:
+// res, err := wallet.API.PayContext(ctx, from, to, amount, fee)
+// if errors.Is(err, wallet.ErrInsufficientBalance) { ... }
+//
+// Deprecated: 请使用 PayContext，该方法会在下个大版本移除。
 func (w *Client) Pay(address string, toAddress string, amount float64, fee float64) (int, map[string]interface{}) {
-	return w.Request(MethodPost, w.fullURL(fmt.Sprintf("/%s/pay/%s/%f/%f", address, toAddress, amount, fee)), nil)
+	return w.RequestContext(context.Background(), MethodPost, w.fullURL(fmt.Sprintf("/%s/pay/%s/%f/%f", address, toAddress, amount, fee)), nil)
+}
+
+// PayContext 支付，支持 ctx 超时/取消控制
+// PATH: /:address(\\w{34})/pay/:to_address(\\w{34})/:amount/:fee
+// return 200: { balance(float), bill_id(string) }
+// return 600: { balance(float) } 余额不足
+func (w *Client) PayContext(ctx context.Context, address string, toAddress string, amount float64, fee float64) (*PayResponse, error) {
+	idemKey := newIdempotencyKey("pay", address, toAddress, amount, fee)
+	status, data, body, resp, err := w.doRequest(ctx, MethodPost, w.fullURL(fmt.Sprintf("/%s/pay/%s/%f/%f", address, toAddress, amount, fee)), nil, idemKey)
+	res := &PayResponse{Body: body, Http: resp}
+	if err != nil {
+		res.Err = err
+		return res, err
+	}
+	if err := newStatusError(status, data); err != nil {
+		res.Err = err
+		return res, err
+	}
+	res.Result.Balance, _ = data["balance"].(float64)
+	res.Result.BillID, _ = data["bill_id"].(string)
+	return res, nil
 }
 
 // Fee 支付小费
 // PATH: /:address(\\w{34})/fee/:fee
 // return 200: { balance(float) }
 // return 600: { balance(float), bill_id(string) } 余额不足
+//
+// Deprecated: 请使用 FeeContext，该方法会在下个大版本移除。
 func (w *Client) Fee(address string, fee float64) (int, map[string]interface{}) {
-	return w.Request(MethodPost, w.fullURL(fmt.Sprintf("/%s/fee//%f", address, fee)), nil)
+	return w.RequestContext(context.Background(), MethodPost, w.fullURL(fmt.Sprintf("/%s/fee//%f", address, fee)), nil)
+}
+
+// FeeContext 支付小费，支持 ctx 超时/取消控制
+// PATH: /:address(\\w{34})/fee/:fee
+// return 200: { balance(float) }
+// return 600: { balance(float), bill_id(string) } 余额不足
+func (w *Client) FeeContext(ctx context.Context, address string, fee float64) (*FeeResponse, error) {
+	idemKey := newIdempotencyKey("fee", address, fee)
+	status, data, body, resp, err := w.doRequest(ctx, MethodPost, w.fullURL(fmt.Sprintf("/%s/fee//%f", address, fee)), nil, idemKey)
+	res := &FeeResponse{Body: body, Http: resp}
+	if err != nil {
+		res.Err = err
+		return res, err
+	}
+	if err := newStatusError(status, data); err != nil {
+		res.Err = err
+		return res, err
+	}
+	res.Result.Balance, _ = data["balance"].(float64)
+	res.Result.BillID, _ = data["bill_id"].(string)
+	return res, nil
 }
 
 // AdvanceFee 预付小费
@@ -129,9 +389,32 @@ func (w *Client) Fee(address string, fee float64) (int, map[string]interface{})
 // return 600: { balance(float), bill_id(string) } 余额不足
 //
 // example:
-// wallet.API.AdvanceFee("qcAhh3TBa9QQxePudRXBoVe89pVowoab63", 0.1)
+// wallet.API.AdvanceFeeContext(ctx, "qcAhh3TBa9QQxePudRXBoVe89pVowoab63", 0.1)
+//
+// Deprecated: 请使用 AdvanceFeeContext，该方法会在下个大版本移除。
 func (w *Client) AdvanceFee(address string, fee float64) (int, map[string]interface{}) {
-	return w.Request(MethodPost, w.fullURL(fmt.Sprintf("/advance/%s/fee/%f", address, fee)), nil)
+	return w.RequestContext(context.Background(), MethodPost, w.fullURL(fmt.Sprintf("/advance/%s/fee/%f", address, fee)), nil)
+}
+
+// AdvanceFeeContext 预付小费，支持 ctx 超时/取消控制
+// PATH: /advance/:address(\\w{34})/fee/:fee
+// return 200: { balance(float) }
+// return 600: { balance(float), bill_id(string) } 余额不足
+func (w *Client) AdvanceFeeContext(ctx context.Context, address string, fee float64) (*AdvanceFeeResponse, error) {
+	idemKey := newIdempotencyKey("advance_fee", address, fee)
+	status, data, body, resp, err := w.doRequest(ctx, MethodPost, w.fullURL(fmt.Sprintf("/advance/%s/fee/%f", address, fee)), nil, idemKey)
+	res := &AdvanceFeeResponse{Body: body, Http: resp}
+	if err != nil {
+		res.Err = err
+		return res, err
+	}
+	if err := newStatusError(status, data); err != nil {
+		res.Err = err
+		return res, err
+	}
+	res.Result.Balance, _ = data["balance"].(float64)
+	res.Result.BillID, _ = data["bill_id"].(string)
+	return res, nil
 }
 
 // ApproveAdvanceFee 批准预付小费（批量）
@@ -140,22 +423,74 @@ func (w *Client) AdvanceFee(address string, fee float64) (int, map[string]interf
 // return 200: { fee_sum(float:小费总额), affected(int:成功笔数) }
 //
 // example:
-// wallet.API.ApproveAdvanceFee([]string{"52", "53"})
+// wallet.API.ApproveAdvanceFeeContext(ctx, []string{"52", "53"})
+//
+// Deprecated: 请使用 ApproveAdvanceFeeContext，该方法会在下个大版本移除。
 func (w *Client) ApproveAdvanceFee(billIDs []string) (int, map[string]interface{}) {
-	return w.Request(MethodPost, w.fullURL("/advance/approve_fee"), map[string]interface{}{
+	return w.RequestContext(context.Background(), MethodPost, w.fullURL("/advance/approve_fee"), map[string]interface{}{
 		"bills": billIDs,
 	})
 }
 
+// ApproveAdvanceFeeContext 批准预付小费（批量），支持 ctx 超时/取消控制
+// PATH: /advance/approve_fee
+// body(json): { "bills": [billId... ] }
+// return 200: { fee_sum(float:小费总额), affected(int:成功笔数) }
+func (w *Client) ApproveAdvanceFeeContext(ctx context.Context, billIDs []string) (*ApproveAdvanceFeeResponse, error) {
+	idemKey := newIdempotencyKey("approve_fee", strings.Join(billIDs, ","))
+	status, data, body, resp, err := w.doRequest(ctx, MethodPost, w.fullURL("/advance/approve_fee"), map[string]interface{}{
+		"bills": billIDs,
+	}, idemKey)
+	res := &ApproveAdvanceFeeResponse{Body: body, Http: resp}
+	if err != nil {
+		res.Err = err
+		return res, err
+	}
+	if err := newStatusError(status, data); err != nil {
+		res.Err = err
+		return res, err
+	}
+	res.Result.FeeSum, _ = data["fee_sum"].(float64)
+	affected, _ := data["affected"].(float64)
+	res.Result.Affected = int(affected)
+	return res, nil
+}
+
 // CancelAdvanceFee 取消预付小费（单个）
 // PATH: /advance/approve_fee
 // body(json): { "bill": billId }
 // return 200: { fee(float), affected(int:成功笔数) }
 //
 // example:
-// wallet.API.CancelAdvanceFee("54")
+// wallet.API.CancelAdvanceFeeContext(ctx, "54")
+//
+// Deprecated: 请使用 CancelAdvanceFeeContext，该方法会在下个大版本移除。
 func (w *Client) CancelAdvanceFee(billID string) (int, map[string]interface{}) {
-	return w.Request(MethodPost, w.fullURL("/advance/cancel_fee"), map[string]interface{}{
+	return w.RequestContext(context.Background(), MethodPost, w.fullURL("/advance/cancel_fee"), map[string]interface{}{
 		"bill": billID,
 	})
 }
+
+// CancelAdvanceFeeContext 取消预付小费（单个），支持 ctx 超时/取消控制
+// PATH: /advance/cancel_fee
+// body(json): { "bill": billId }
+// return 200: { fee(float), affected(int:成功笔数) }
+func (w *Client) CancelAdvanceFeeContext(ctx context.Context, billID string) (*CancelAdvanceFeeResponse, error) {
+	idemKey := newIdempotencyKey("cancel_fee", billID)
+	status, data, body, resp, err := w.doRequest(ctx, MethodPost, w.fullURL("/advance/cancel_fee"), map[string]interface{}{
+		"bill": billID,
+	}, idemKey)
+	res := &CancelAdvanceFeeResponse{Body: body, Http: resp}
+	if err != nil {
+		res.Err = err
+		return res, err
+	}
+	if err := newStatusError(status, data); err != nil {
+		res.Err = err
+		return res, err
+	}
+	res.Result.Fee, _ = data["fee"].(float64)
+	affected, _ := data["affected"].(float64)
+	res.Result.Affected = int(affected)
+	return res, nil
+}
@@ -0,0 +1,33 @@
+package wallet
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// TestHTTPCliConcurrentSafe 复现并验证评审中指出的缺陷修复：并发调用 httpCli()
+// 在默认 http.Client 尚未构造时不应触发数据竞争，且所有调用方应当拿到同一个实例
+// （跑在 go test -race 下才能真正捕获原先的竞争条件）。
+func TestHTTPCliConcurrentSafe(t *testing.T) {
+	w := &Client{conf: &Config{}}
+
+	const n = 20
+	results := make([]*http.Client, n)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			results[i] = w.httpCli()
+		}(i)
+	}
+	wg.Wait()
+
+	first := results[0]
+	for i, c := range results {
+		if c != first {
+			t.Fatalf("expected all concurrent httpCli() calls to return the same instance, call %d differed", i)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package wallet
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/panjiang/golog"
+)
+
+// replayWindow 签名时间戳允许的最大偏移，超出则视为重放或无效请求
+const replayWindow = 5 * time.Minute
+
+// defaultDedupTTL 幂等存储默认的去重窗口，用于识别同一事件 ID 的重复投递。
+// 与 replayWindow 分开：后端的重试/退避可能在首次投递数分钟到数小时后才重新投递
+// 同一事件（带着新的时间戳和签名），若复用 replayWindow 作为去重窗口，
+// 这类合法的重新投递会在去重记录过期后被当成新事件再次 dispatch，
+// 对 BillSettledEvent/RechargeConfirmedEvent 这类资金类事件造成重复入账。
+const defaultDedupTTL = 24 * time.Hour
+
+// dedupTTL 返回 Config.WebhookDedupTTL，未设置时回退到 defaultDedupTTL
+func dedupTTL(cfg *Config) time.Duration {
+	if cfg != nil && cfg.WebhookDedupTTL > 0 {
+		return cfg.WebhookDedupTTL
+	}
+	return defaultDedupTTL
+}
+
+// BillSettledEvent 账单结算完成事件
+type BillSettledEvent struct {
+	BillID  string  `json:"bill_id"`
+	Address string  `json:"address"`
+	Amount  float64 `json:"amount"`
+	Balance float64 `json:"balance"`
+}
+
+// RechargeConfirmedEvent 充值到账确认事件
+type RechargeConfirmedEvent struct {
+	Address string  `json:"address"`
+	Amount  float64 `json:"amount"`
+	Balance float64 `json:"balance"`
+}
+
+// AddressCreatedEvent 钱包地址创建事件
+type AddressCreatedEvent struct {
+	Account string `json:"account"`
+	Address string `json:"address"`
+}
+
+// AdvanceFeeApprovedEvent 预付小费批准事件
+type AdvanceFeeApprovedEvent struct {
+	BillIDs  []string `json:"bill_ids"`
+	FeeSum   float64  `json:"fee_sum"`
+	Affected int      `json:"affected"`
+}
+
+// webhookEnvelope 是后端投递的通用事件信封，data 依 type 解析为具体事件结构体
+type webhookEnvelope struct {
+	ID   string          `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// IdempotencyStore 用于识别重复投递的事件 ID，默认提供内存实现，
+// 也可以实现该接口接入 Redis 等共享存储以支持多实例部署。
+// Seen 和 MarkDone 分离，是为了只在 dispatch 成功后才落地去重记录——
+// 否则一次失败的投递会让后续重试被误判为重复而永远不再调用处理函数。
+type IdempotencyStore interface {
+	// Seen 返回 id 是否在 window 时间内已成功处理过
+	Seen(ctx context.Context, id string, window time.Duration) (bool, error)
+	// MarkDone 标记 id 已成功处理，window 时间内对该 id 的重复投递会被 Seen 去重
+	MarkDone(ctx context.Context, id string, window time.Duration) error
+}
+
+// memoryIdempotencyStore 进程内的默认幂等存储实现
+type memoryIdempotencyStore struct {
+	mu   sync.Mutex
+	done map[string]time.Time
+}
+
+// NewMemoryIdempotencyStore 创建一个进程内的幂等存储，仅适合单实例部署
+func NewMemoryIdempotencyStore() IdempotencyStore {
+	return &memoryIdempotencyStore{done: make(map[string]time.Time)}
+}
+
+func (s *memoryIdempotencyStore) Seen(ctx context.Context, id string, window time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.done[id]
+	if !ok {
+		return false, nil
+	}
+	if time.Since(t) > window {
+		delete(s.done, id)
+		return false, nil
+	}
+	return true, nil
+}
+
+func (s *memoryIdempotencyStore) MarkDone(ctx context.Context, id string, window time.Duration) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, t := range s.done {
+		if now.Sub(t) > window {
+			delete(s.done, k)
+		}
+	}
+	s.done[id] = now
+	return nil
+}
+
+// Dispatcher 将解码后的 webhook 事件分发给按事件类型注册的处理函数
+type Dispatcher struct {
+	mu       sync.RWMutex
+	handlers map[string][]reflect.Value
+}
+
+// NewDispatcher 创建一个空的事件分发器
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{handlers: make(map[string][]reflect.Value)}
+}
+
+var (
+	ctxInterfaceType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errInterfaceType = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// On 注册 eventType 的处理函数，handler 形如 func(ctx context.Context, event *XxxEvent) error，
+// event 的具体类型需与该 eventType 对应的事件结构体一致。
+// handler 形状不符会直接 panic：这是注册时的编码错误，应当在启动时暴露，
+// 而不是等到线上 webhook 请求触发 dispatch 时才在 reflect 调用处 panic。
+func (d *Dispatcher) On(eventType string, handler interface{}) {
+	v := reflect.ValueOf(handler)
+	t := v.Type()
+	if t.Kind() != reflect.Func ||
+		t.NumIn() != 2 || t.In(0) != ctxInterfaceType || t.In(1).Kind() != reflect.Ptr || t.In(1).Elem().Kind() != reflect.Struct ||
+		t.NumOut() != 1 || t.Out(0) != errInterfaceType {
+		log.Panic(fmt.Sprintf("wallet: Dispatcher.On(%q): handler must be func(context.Context, *XxxEvent) error, got %T", eventType, handler))
+	}
+	d.mu.Lock()
+	d.handlers[eventType] = append(d.handlers[eventType], v)
+	d.mu.Unlock()
+}
+
+// dispatch 将 data 解析为 eventType 对应处理函数的入参类型并依次调用；
+// eventType 没有注册任何处理函数时记录一条日志再放行（ack），
+// 便于及时发现事件类型拼写错误或服务端新增了客户端尚未接入的事件类型
+func (d *Dispatcher) dispatch(ctx context.Context, eventType string, data json.RawMessage) error {
+	d.mu.RLock()
+	handlers := append([]reflect.Value(nil), d.handlers[eventType]...)
+	d.mu.RUnlock()
+
+	if len(handlers) == 0 {
+		log.Debugf("wallet: webhook event type %q has no registered handler, acking without dispatch", eventType)
+		return nil
+	}
+
+	for _, h := range handlers {
+		argType := h.Type().In(1).Elem()
+		event := reflect.New(argType)
+		if err := json.Unmarshal(data, event.Interface()); err != nil {
+			return fmt.Errorf("wallet: decode %s event: %w", eventType, err)
+		}
+		results := h.Call([]reflect.Value{reflect.ValueOf(ctx), event})
+		if errVal := results[0].Interface(); errVal != nil {
+			return errVal.(error)
+		}
+	}
+	return nil
+}
+
+// NewWebhookHandler 创建处理钱包后端异步回调（账单结算、充值到账等）的 http.Handler，
+// 使用 Config.WebhookSecret 校验 HMAC-SHA256 签名并拒绝重放请求。
+// store 为 nil 时使用进程内的默认幂等存储（NewMemoryIdempotencyStore）。
+// 响应 2xx 表示已确认（ack），非 2xx 表示处理失败，调用方应当重新投递。
+func NewWebhookHandler(cfg *Config, dispatcher *Dispatcher, store IdempotencyStore) http.HandlerFunc {
+	if store == nil {
+		store = NewMemoryIdempotencyStore()
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "read body failed", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		ts, sig, err := parseWebhookSignature(r.Header.Get("X-Wallet-Signature"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if d := time.Since(time.Unix(ts, 0)); d < -replayWindow || d > replayWindow {
+			http.Error(w, "signature timestamp outside of allowed window", http.StatusUnauthorized)
+			return
+		}
+		if !verifyWebhookSignature(cfg.WebhookSecret, ts, body, sig) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		var env webhookEnvelope
+		if err := json.Unmarshal(body, &env); err != nil {
+			http.Error(w, "invalid payload", http.StatusBadRequest)
+			return
+		}
+
+		ttl := dedupTTL(cfg)
+
+		seen, err := store.Seen(r.Context(), env.ID, ttl)
+		if err != nil {
+			http.Error(w, "idempotency check failed", http.StatusInternalServerError)
+			return
+		}
+		if seen {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if err := dispatcher.dispatch(r.Context(), env.Type, env.Data); err != nil {
+			log.Debugf("wallet: webhook dispatch failed type=%s id=%s err=%v", env.Type, env.ID, err)
+			http.Error(w, "handler failed", http.StatusInternalServerError)
+			return
+		}
+
+		// 只在 dispatch 成功后才标记为已处理，失败的投递必须在下一次重试时再次调用处理函数
+		if err := store.MarkDone(r.Context(), env.ID, ttl); err != nil {
+			log.Debugf("wallet: webhook mark done failed id=%s err=%v", env.ID, err)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// parseWebhookSignature 解析形如 "t=1690000000,v1=abcdef" 的签名头
+func parseWebhookSignature(header string) (int64, string, error) {
+	var ts int64
+	var sig string
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			v, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return 0, "", fmt.Errorf("wallet: invalid signature timestamp")
+			}
+			ts = v
+		case "v1":
+			sig = kv[1]
+		}
+	}
+	if ts == 0 || sig == "" {
+		return 0, "", fmt.Errorf("wallet: missing signature header")
+	}
+	return ts, sig, nil
+}
+
+// verifyWebhookSignature 校验 HMAC-SHA256(secret, "{ts}.{body}") 是否与 sig 一致
+func verifyWebhookSignature(secret string, ts int64, body []byte, sig string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(sig))
+}
@@ -0,0 +1,113 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func signedWebhookRequest(t *testing.T, secret string, env webhookEnvelope) *http.Request {
+	t.Helper()
+	body, err := json.Marshal(env)
+	if err != nil {
+		t.Fatalf("marshal envelope: %v", err)
+	}
+	ts := time.Now().Unix()
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	sig := hex.EncodeToString(mac.Sum(nil))
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/wallet", bytes.NewReader(body))
+	req.Header.Set("X-Wallet-Signature", "t="+strconv.FormatInt(ts, 10)+",v1="+sig)
+	return req
+}
+
+// TestWebhookRedeliveryRetriesFailedHandler 复现并验证评审中指出的缺陷修复：
+// 第一次投递处理失败（5xx）后，使用相同事件 id 的重试必须再次调用处理函数，
+// 而不是被幂等存储直接 ack 掉。
+func TestWebhookRedeliveryRetriesFailedHandler(t *testing.T) {
+	cfg := &Config{WebhookSecret: "test-secret"}
+	dispatcher := NewDispatcher()
+
+	var calls int32
+	dispatcher.On("bill.settled", func(ctx context.Context, event *BillSettledEvent) error {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			return errAlwaysFailsOnce
+		}
+		return nil
+	})
+
+	store := NewMemoryIdempotencyStore()
+	handler := NewWebhookHandler(cfg, dispatcher, store)
+
+	env := webhookEnvelope{ID: "evt_1", Type: "bill.settled", Data: json.RawMessage(`{"bill_id":"1"}`)}
+
+	rec1 := httptest.NewRecorder()
+	handler(rec1, signedWebhookRequest(t, cfg.WebhookSecret, env))
+	if rec1.Code != http.StatusInternalServerError {
+		t.Fatalf("first delivery: expected 500, got %d", rec1.Code)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected handler called once after first delivery, got %d", got)
+	}
+
+	rec2 := httptest.NewRecorder()
+	handler(rec2, signedWebhookRequest(t, cfg.WebhookSecret, env))
+	if rec2.Code != http.StatusOK {
+		t.Fatalf("redelivery: expected 200, got %d", rec2.Code)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("expected handler called again on redelivery after failure, got %d", got)
+	}
+
+	rec3 := httptest.NewRecorder()
+	handler(rec3, signedWebhookRequest(t, cfg.WebhookSecret, env))
+	if rec3.Code != http.StatusOK {
+		t.Fatalf("second redelivery: expected 200, got %d", rec3.Code)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("expected handler NOT called again once already acked, got %d", got)
+	}
+}
+
+var errAlwaysFailsOnce = &testError{msg: "handler failed"}
+
+type testError struct{ msg string }
+
+func (e *testError) Error() string { return e.msg }
+
+// TestDispatcherOnRejectsWrongHandlerShape 复现并验证评审中指出的缺陷修复：
+// 注册形状不符的 handler 应当在 On() 时就 panic，而不是留到 dispatch 时才
+// 在 reflect 调用处 panic。
+func TestDispatcherOnRejectsWrongHandlerShape(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected On to panic on a malformed handler")
+		}
+	}()
+	d := NewDispatcher()
+	d.On("bill.settled", func(event *BillSettledEvent) error { return nil })
+}
+
+// TestDispatcherDispatchUnregisteredEventTypeAcksWithoutPanic 复现并验证评审中指出的缺陷修复：
+// 没有注册处理函数的事件类型应当被安全地 ack（不 panic、不报错），
+// 便于配合日志及时发现拼写错误或未接入的新事件类型。
+func TestDispatcherDispatchUnregisteredEventTypeAcksWithoutPanic(t *testing.T) {
+	d := NewDispatcher()
+	if err := d.dispatch(context.Background(), "unknown.type", json.RawMessage(`{}`)); err != nil {
+		t.Fatalf("expected dispatch of unregistered event type to return nil, got %v", err)
+	}
+}